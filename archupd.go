@@ -20,6 +20,9 @@ import (
 
 	"github.com/pkg/diff"
 	diff_write "github.com/pkg/diff/write"
+
+	"github.com/c4rlo/archupd/internal/aur"
+	"github.com/c4rlo/archupd/internal/log"
 )
 
 const NEWSFEED_URL = "https://archlinux.org/feeds/news/"
@@ -29,17 +32,30 @@ const HELP_STR = `
   Arch Linux updater. Run without args and it will:
 
   - Run "sudo pacman -Sc" to clean up old packages.
-  - Run "sudo pacman -Syu" to update outdated packages.
+  - Run "sudo pacman -Syu" to update outdated packages (pass -i/--interactive to pick which).
   - Show relevant pacman logfile contents, which includes the old and new version of each package.
   - Show any new package changelog entries.
-  - Offer to remove packages that have become unrequired.
-  - Display any new official Arch Linux news from RSS feed.
+  - Offer to remove packages that have become unrequired, including ones that are
+    only unneeded transitively (pass --clean-deep to look for the latter even
+    when there are no plain orphans).
+  - Offer to rebuild installed -git/-svn/-hg packages whose upstream has moved.
+  - Check the AUR for updates to foreign packages (pass --no-aur to skip).
+  - Display any new official Arch Linux news from RSS feed (pass --news-all,
+    --news-since, --news-quiet or --news-bottom-up to control what's shown).
+
+  Run "archupd news" to print the news feed on its own, without touching pacman.
 `
 
 var PACMAN_LOG_ALPM_MARKER = []byte(" [ALPM] ")
 var CHANGELOG_PACKAGE_REGEXP = regexp.MustCompile(`^Changelog for (.+):$`)
 
 var helpFlag = false
+var noAurFlag = false
+var interactiveFlag = false
+var newsAllFlag = false
+var newsSinceFlag = ""
+var newsQuietFlag = false
+var newsBottomUpFlag = false
 
 func init() {
 	const helpUsage = "show help"
@@ -47,6 +63,17 @@ func init() {
 	flag.BoolVar(&helpFlag, "?", false, helpUsage)
 	flag.BoolVar(&helpFlag, "help", false, helpUsage)
 
+	flag.BoolVar(&noAurFlag, "no-aur", false, "skip checking the AUR for foreign package updates")
+
+	const interactiveUsage = "pick which pending upgrades to install, instead of upgrading everything"
+	flag.BoolVar(&interactiveFlag, "i", false, interactiveUsage)
+	flag.BoolVar(&interactiveFlag, "interactive", false, interactiveUsage)
+
+	flag.BoolVar(&newsAllFlag, "news-all", false, "print every news item, regardless of what was already seen")
+	flag.StringVar(&newsSinceFlag, "news-since", "", "print news items published since this date (2006-01-02), ignoring previously seen state")
+	flag.BoolVar(&newsQuietFlag, "news-quiet", false, "print nothing when there is no new Arch Linux news")
+	flag.BoolVar(&newsBottomUpFlag, "news-bottom-up", false, "print news oldest first, so the newest item is last (handy when piping into a pager)")
+
 	flag.Usage = showHelp
 }
 
@@ -82,9 +109,14 @@ func showHelp() {
 	fmt.Printf("Usage: %s\n%s", os.Args[0], HELP_STR)
 }
 
+// VCSState records, for each installed VCS/devel package, the last commit
+// or revision seen at each of its PKGBUILD source URLs.
+type VCSState map[string]map[string]string
+
 type State struct {
 	LastModified   string    `json:"last_modified"`
 	LatestItemTime time.Time `json:"latest_seen"`
+	VCSState       VCSState  `json:"vcs_state,omitempty"`
 }
 
 func stateFileName() string {
@@ -113,46 +145,85 @@ func readState() State {
 	return state
 }
 
+// writeState writes state to disk atomically, via a temp file and rename,
+// so that a crash or a concurrent reader never observes a half-written file.
 func writeState(state *State) {
 	fileName := stateFileName()
 	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
 		fmt.Println(err)
 		return
 	}
-	f, err := os.Create(fileName)
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp-*")
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(state)
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(state); err != nil {
+		tmp.Close()
+		fmt.Println(err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), fileName); err != nil {
+		fmt.Println(err)
+	}
 }
 
-func readNews(ch chan<- string) {
-	defer close(ch)
+// newsOptions controls how readNews filters and orders the items it
+// returns.
+type newsOptions struct {
+	since    time.Time // explicit cutoff; overrides state.LatestItemTime if non-zero
+	all      bool      // ignore both since and state.LatestItemTime; return every item
+	bottomUp bool      // reverse order, so the newest item is last
+}
 
-	state := readState()
+func newsOptionsFromFlags() (newsOptions, error) {
+	opts := newsOptions{all: newsAllFlag, bottomUp: newsBottomUpFlag}
+	if newsSinceFlag != "" {
+		since, err := time.Parse("2006-01-02", newsSinceFlag)
+		if err != nil {
+			return opts, fmt.Errorf("invalid --news-since date %q: %w", newsSinceFlag, err)
+		}
+		opts.since = since
+	}
+	return opts, nil
+}
+
+// readNews fetches the Arch Linux news feed and returns the items that are
+// newsworthy per opts. It performs no printing, which keeps it testable
+// against a fake http.RoundTripper.
+//
+// It only ever mutates state.LastModified and state.LatestItemTime, never
+// state.VCSState, so it is safe to call concurrently with code that only
+// mutates state.VCSState (e.g. checkVCSUpdates) against the very same
+// *State: the two touch disjoint fields of it.
+func readNews(client *http.Client, state *State, opts newsOptions) ([]FeedItem, error) {
+	prevLatest := state.LatestItemTime
 
 	req, err := http.NewRequest(http.MethodGet, NEWSFEED_URL, nil)
 	if err != nil {
-		ch <- "Arch Linux news: failed to formulate request: " + err.Error()
-		return
+		return nil, fmt.Errorf("failed to formulate request: %w", err)
 	}
-	if state.LastModified != "" {
+	// A conditional request only tells us whether the feed changed, not
+	// whether it covers the date range the caller asked for, so skip it
+	// whenever a cutoff is being overridden.
+	if state.LastModified != "" && !opts.all && opts.since.IsZero() {
 		req.Header.Add("If-Modified-Since", state.LastModified)
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		ch <- "Arch Linux news: failed to send request: " + err.Error()
-		return
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode == http.StatusNotModified {
-		ch <- "No Arch Linux news."
-		return
+		return nil, nil
 	}
 	if resp.StatusCode != http.StatusOK {
-		ch <- "Arch Linux news: unexpected HTTP status: " + resp.Status
-		return
+		return nil, fmt.Errorf("unexpected HTTP status: %s", resp.Status)
 	}
 
 	if lastMod := resp.Header.Values("Last-Modified"); lastMod != nil {
@@ -161,82 +232,89 @@ func readNews(ch chan<- string) {
 
 	decoder := xml.NewDecoder(resp.Body)
 	var feed Feed
-	if err = decoder.Decode(&feed); err != nil {
-		ch <- "Arch Linux news: failed to decode feed: " + err.Error()
-		return
+	if err := decoder.Decode(&feed); err != nil {
+		return nil, fmt.Errorf("failed to decode feed: %w", err)
 	}
 
-	defer writeState(&state)
-
 	items := feed.Items
 	sort.Slice(items, func(i, j int) bool {
 		return items[i].Time.After(items[j].Time.Time)
 	})
-
-	if len(items) == 0 {
-		ch <- "No Arch Linux news (empty feed)."
-		return
+	if len(items) > 0 {
+		state.LatestItemTime = items[0].Time.Time
 	}
 
-	foundAny := false
-	for _, item := range items {
-		if item.Time.After(state.LatestItemTime) {
-			if !foundAny {
-				ch <- "Arch Linux news:"
+	var result []FeedItem
+	if opts.all {
+		result = items
+	} else {
+		cutoff := prevLatest
+		if !opts.since.IsZero() {
+			cutoff = opts.since
+		}
+		for _, item := range items {
+			if item.Time.After(cutoff) {
+				result = append(result, item)
 			}
-			ch <- fmt.Sprintf("  - %s: %s (%s)",
-				item.Time.Local().Format("2006-01-02 15:04"), item.Title, item.Link)
-			foundAny = true
 		}
 	}
 
-	state.LatestItemTime = items[0].Time.Time
-
-	if !foundAny {
-		ch <- "No Arch Linux news."
+	if opts.bottomUp {
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
 	}
+
+	return result, nil
 }
 
-func pacman(args ...string) error {
-	cmdArgs := append([]string{"pacman"}, args...)
-	cmd := exec.Command("sudo", cmdArgs...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// renderNews formats items the way they are printed to the user. An empty
+// slice renders as a single "no news" line; a caller that wants
+// --news-quiet behaviour checks len(items) itself before calling
+// renderNews, as fetchAndRenderNews does.
+func renderNews(items []FeedItem) []string {
+	if len(items) == 0 {
+		return []string{"No Arch Linux news."}
+	}
+	lines := make([]string, 0, len(items)+1)
+	lines = append(lines, "Arch Linux news:")
+	for _, item := range items {
+		lines = append(lines, fmt.Sprintf("  - %s: %s (%s)",
+			item.Time.Local().Format("2006-01-02 15:04"), item.Title, item.Link))
+	}
+	return lines
 }
 
-func removeSuperfluousPackages() error {
-	var output strings.Builder
-	cmd := exec.Command("sudo", "pacman", "-Qqtd")
-	cmd.Stdout = &output
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+// fetchAndRenderNews wraps readNews with the quiet/render handling shared
+// by the main update flow and the standalone "archupd news" subcommand. It
+// mutates state in place rather than reading and writing it itself, so
+// that callers running it concurrently with other state updates (e.g. the
+// VCS check) persist everything with a single writeState call instead of
+// two racing ones.
+func fetchAndRenderNews(logger *log.Logger, client *http.Client, opts newsOptions, state *State) []string {
+	items, err := readNews(client, state, opts)
 	if err != nil {
-		if err, ok := err.(*exec.ExitError); ok {
-			if err.ExitCode() == 1 {
-				fmt.Println("\nNo superfluous packages.")
-				return nil
-			}
-		}
-		return err
+		logger.Error("%v", err)
+		return nil
 	}
-	pkgs := strings.Split(strings.TrimRight(output.String(), "\n"), "\n")
-	if len(pkgs) == 0 {
+	logger.Debug("found %d newsworthy item(s)", len(items))
+	if len(items) == 0 && newsQuietFlag {
 		return nil
 	}
+	return renderNews(items)
+}
 
-	fmt.Println("\nSuperfluous packages can be removed:")
-	args := []string{"pacman", "-Rs"}
-	args = append(args, pkgs...)
-	cmd = exec.Command("sudo", args...)
+func pacman(args ...string) error {
+	cmdArgs := append([]string{"pacman"}, args...)
+	cmd := exec.Command("sudo", cmdArgs...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
-func getChangelogs() (map[string]string, error) {
+func getChangelogs(logger *log.Logger) (map[string]string, error) {
+	logger.Debug("running pacman -Qc")
 	cmd := exec.Command("pacman", "-Qc")
 	outputReader, err := cmd.StdoutPipe()
 	if err != nil {
@@ -268,6 +346,7 @@ func getChangelogs() (map[string]string, error) {
 	if err := cmd.Wait(); err != nil {
 		return nil, err
 	}
+	logger.Debug("found changelogs for %d package(s)", len(result))
 	return result, nil
 }
 
@@ -291,12 +370,12 @@ func (m *logMonitor) lines() *bufio.Scanner {
 	return bufio.NewScanner(m)
 }
 
-func showChangelogDiff(changelogsPre, changelogsPost map[string]string) {
+func showChangelogDiff(logger *log.Logger, changelogsPre, changelogsPost map[string]string) {
 	foundAny := false
 	for pkg, logPost := range changelogsPost {
 		if logPre, ok := changelogsPre[pkg]; ok && logPre != logPost {
 			if !foundAny {
-				fmt.Println("\nChangelog diffs:\n")
+				logger.Info("\nChangelog diffs:\n")
 			}
 			err := diff.Text(
 				pkg+" (before)",
@@ -308,12 +387,65 @@ func showChangelogDiff(changelogsPre, changelogsPost map[string]string) {
 			)
 			foundAny = true
 			if err != nil {
-				fmt.Println(err)
+				logger.Error("%s: %v", pkg, err)
 			}
 		}
 	}
 	if !foundAny {
-		fmt.Println("\nNo updated changelogs.")
+		logger.Info("\nNo updated changelogs.")
+	}
+}
+
+func showAurUpdates(logger *log.Logger) {
+	upgrades, err := aur.CheckUpdates()
+	if err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	if len(upgrades) == 0 {
+		logger.Info("\nNo AUR updates.")
+		return
+	}
+	logger.Info("\nAUR packages with updates available:")
+	for _, u := range upgrades {
+		logger.Info("  - %s: %s -> %s", u.Name, u.Installed, u.Latest)
+	}
+	showAurPKGBUILDDiffs(logger, upgrades)
+}
+
+// showAurPKGBUILDDiffs shows what changed in each upgradeable AUR package's
+// PKGBUILD since it was last cached here. AUR packages carry no alpm
+// changelog, so this is the closest AUR equivalent of showChangelogDiff: a
+// diff of the cached PKGBUILD against the one currently published in the
+// AUR, using the same diff.Text rendering. The newly-fetched PKGBUILD is
+// then cached for next time, the same cache pkgbuildPath uses for devel
+// packages.
+func showAurPKGBUILDDiffs(logger *log.Logger, upgrades []aur.Upgrade) {
+	for _, u := range upgrades {
+		path := pkgbuildPath(u.Name)
+		cached, _ := os.ReadFile(path) // missing cache is fine: diff against empty
+		latest, err := aur.FetchPKGBUILD(u.Name)
+		if err != nil {
+			logger.Error("%s: %v", u.Name, err)
+			continue
+		}
+		if string(cached) != latest {
+			logger.Info("\nPKGBUILD diff for %s:\n", u.Name)
+			err := diff.Text(
+				u.Name+" (cached)",
+				u.Name+" (AUR)",
+				string(cached),
+				latest,
+				os.Stdout,
+				diff_write.TerminalColor(),
+			)
+			if err != nil {
+				logger.Error("%s: %v", u.Name, err)
+			}
+		}
+		if err := cachePKGBUILD(path, latest); err != nil {
+			logger.Error("caching PKGBUILD for %s: %v", u.Name, err)
+		}
 	}
 }
 
@@ -324,26 +456,75 @@ func exitOnError(err error) {
 	}
 }
 
+// newsHTTPClient is shared by the news fetch in main() and in "archupd
+// news"; a bounded timeout keeps a slow feed response from outlasting the
+// rest of an update run.
+var newsHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// runNewsCommand implements "archupd news": print the news feed and exit,
+// without touching pacman at all.
+func runNewsCommand(args []string) {
+	flag.CommandLine.Parse(args)
+	if helpFlag {
+		showHelp()
+		return
+	}
+	opts, err := newsOptionsFromFlags()
+	exitOnError(err)
+	state := readState()
+	lines := fetchAndRenderNews(log.New("news"), newsHTTPClient, opts, &state)
+	writeState(&state)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "news" {
+		runNewsCommand(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 	if helpFlag {
 		showHelp()
 		return
 	}
 
-	newsCh := make(chan string, 10)
-	go readNews(newsCh)
+	newsOpts, err := newsOptionsFromFlags()
+	exitOnError(err)
+
+	newsLogger := log.New("news")
+	alpmLogger := log.New("alpm")
+	changelogLogger := log.New("changelog")
+	cleanupLogger := log.New("cleanup")
+	vcsLogger := log.New("vcs")
+	aurLogger := log.New("aur")
+
+	// state is shared, read once and written once, between this goroutine
+	// (which only touches LastModified/LatestItemTime) and the VCS check
+	// below (which only touches VCSState), so the two don't race to
+	// persist it: see fetchAndRenderNews and readNews.
+	state := readState()
+	newsCh := make(chan []string, 1)
+	go func() {
+		newsCh <- fetchAndRenderNews(newsLogger, newsHTTPClient, newsOpts, &state)
+	}()
 
-	err := pacman("-Sc", "--noconfirm")
+	err = pacman("-Sc", "--noconfirm")
 	exitOnError(err)
 
-	changelogsPre, err := getChangelogs()
+	changelogsPre, err := getChangelogs(changelogLogger)
 	exitOnError(err)
 
 	logMon, err := newLogMonitor(PACMAN_LOG_PATH)
 	exitOnError(err)
 
-	err = pacman("-Syu", "--noconfirm")
+	if interactiveFlag {
+		err = interactiveUpdate()
+	} else {
+		err = pacman("-Syu", "--noconfirm")
+	}
 	exitOnError(err)
 
 	lines := logMon.lines()
@@ -352,25 +533,42 @@ func main() {
 		line := lines.Bytes()
 		if bytes.Contains(line, PACMAN_LOG_ALPM_MARKER) {
 			if !foundALPMLogs {
-				fmt.Println("\nALPM logs:")
+				alpmLogger.Info("\nALPM logs:")
 				foundALPMLogs = true
 			}
-			fmt.Printf("%s\n", line)
+			alpmLogger.Info("%s", line)
 		}
 	}
 
 	if foundALPMLogs {
-		changelogsPost, err := getChangelogs()
+		changelogsPost, err := getChangelogs(changelogLogger)
 		exitOnError(err)
 
-		showChangelogDiff(changelogsPre, changelogsPost)
+		showChangelogDiff(changelogLogger, changelogsPre, changelogsPost)
 
-		err = removeSuperfluousPackages()
+		err = cleanDependencies(cleanupLogger)
 		exitOnError(err)
 	}
 
+	if vcsPkgs, err := checkVCSUpdates(vcsLogger, &state); err != nil {
+		vcsLogger.Error("%v", err)
+	} else if err := offerVCSRebuild(vcsLogger, vcsPkgs); err != nil {
+		vcsLogger.Error("%v", err)
+	}
+
+	// Join the news goroutine before writing state: this happens-before
+	// edge is what makes it safe for writeState below to see both its
+	// LastModified/LatestItemTime updates and the VCSState update above
+	// in one pass, rather than each racing to persist its own copy.
+	newsLines := <-newsCh
+	writeState(&state)
+
+	if !noAurFlag {
+		showAurUpdates(aurLogger)
+	}
+
 	fmt.Println()
-	for s := range newsCh {
-		fmt.Println(s)
+	for _, line := range newsLines {
+		fmt.Println(line)
 	}
 }
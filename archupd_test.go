@@ -0,0 +1,166 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Arch Linux: Recent news updates</title>
+    <item>
+      <title>Newer item</title>
+      <link>https://archlinux.org/news/newer/</link>
+      <guid>https://archlinux.org/news/newer/</guid>
+      <pubDate>Wed, 02 Jul 2025 10:00:00 +0000</pubDate>
+    </item>
+    <item>
+      <title>Older item</title>
+      <link>https://archlinux.org/news/older/</link>
+      <guid>https://archlinux.org/news/older/</guid>
+      <pubDate>Mon, 01 Jan 2024 10:00:00 +0000</pubDate>
+    </item>
+  </channel>
+</rss>
+`
+
+// roundTripperFunc adapts a function to an http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func fakeFeedClient(t *testing.T, body string) *http.Client {
+	t.Helper()
+	return &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Last-Modified": {"Wed, 02 Jul 2025 10:00:00 GMT"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		}),
+	}
+}
+
+func mustParse(t *testing.T, layout, value string) time.Time {
+	t.Helper()
+	tm, err := time.Parse(layout, value)
+	if err != nil {
+		t.Fatalf("time.Parse(%q, %q): %v", layout, value, err)
+	}
+	return tm
+}
+
+func TestReadNews(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     State
+		opts      newsOptions
+		wantTitle []string
+	}{
+		{
+			name:      "default cutoff from state",
+			state:     State{LatestItemTime: mustParse(t, time.RFC3339, "2024-06-01T00:00:00Z")},
+			opts:      newsOptions{},
+			wantTitle: []string{"Newer item"},
+		},
+		{
+			name:      "news-all ignores state",
+			state:     State{LatestItemTime: mustParse(t, time.RFC3339, "2025-06-01T00:00:00Z")},
+			opts:      newsOptions{all: true},
+			wantTitle: []string{"Newer item", "Older item"},
+		},
+		{
+			name:      "news-since overrides state",
+			state:     State{LatestItemTime: mustParse(t, time.RFC3339, "2025-06-01T00:00:00Z")},
+			opts:      newsOptions{since: mustParse(t, "2006-01-02", "2023-01-01")},
+			wantTitle: []string{"Newer item", "Older item"},
+		},
+		{
+			name:      "bottom-up reverses order",
+			state:     State{},
+			opts:      newsOptions{all: true, bottomUp: true},
+			wantTitle: []string{"Older item", "Newer item"},
+		},
+		{
+			name:      "nothing new",
+			state:     State{LatestItemTime: mustParse(t, time.RFC3339, "2025-12-01T00:00:00Z")},
+			opts:      newsOptions{},
+			wantTitle: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeFeedClient(t, testFeed)
+			state := tt.state
+			items, err := readNews(client, &state, tt.opts)
+			if err != nil {
+				t.Fatalf("readNews: %v", err)
+			}
+			var titles []string
+			for _, item := range items {
+				titles = append(titles, item.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.wantTitle) {
+				t.Errorf("titles = %v, want %v", titles, tt.wantTitle)
+			}
+			if state.LastModified != "Wed, 02 Jul 2025 10:00:00 GMT" {
+				t.Errorf("LastModified = %q, want cached header value", state.LastModified)
+			}
+		})
+	}
+}
+
+func TestReadNewsNotModified(t *testing.T) {
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("If-Modified-Since") == "" {
+				t.Errorf("expected a conditional request")
+			}
+			return &http.Response{
+				StatusCode: http.StatusNotModified,
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		}),
+	}
+	want := State{LastModified: "Wed, 02 Jul 2025 10:00:00 GMT"}
+	state := want
+	items, err := readNews(client, &state, newsOptions{})
+	if err != nil {
+		t.Fatalf("readNews: %v", err)
+	}
+	if items != nil {
+		t.Errorf("items = %v, want nil", items)
+	}
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("state changed on 304 response: got %+v, want %+v", state, want)
+	}
+}
+
+func TestRenderNews(t *testing.T) {
+	if got := renderNews(nil); !reflect.DeepEqual(got, []string{"No Arch Linux news."}) {
+		t.Errorf("renderNews(nil) = %v", got)
+	}
+
+	items := []FeedItem{{
+		Title: "Some change",
+		Link:  "https://archlinux.org/news/some-change/",
+		Time:  RSSTime{mustParse(t, time.RFC3339, "2025-07-02T10:00:00Z")},
+	}}
+	got := renderNews(items)
+	if len(got) != 2 || got[0] != "Arch Linux news:" {
+		t.Fatalf("renderNews(items) = %v", got)
+	}
+	if !strings.Contains(got[1], "Some change") || !strings.Contains(got[1], items[0].Link) {
+		t.Errorf("renderNews(items)[1] = %q, missing title or link", got[1])
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+// Hanging-dependency cleanup. Beyond pacman's own orphan detection
+// (pacman -Qqtd, packages installed as a dependency with no reverse
+// dependency at all), this also finds packages that only become unneeded
+// transitively: e.g. a package that depended solely on an orphan, and is
+// itself no longer required by anything once that orphan is gone. The
+// reverse-dependency graph is read from "Required By" / "Optional For" in
+// pacman -Qi, and the transitive closure is computed locally.
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/c4rlo/archupd/internal/intrange"
+	"github.com/c4rlo/archupd/internal/log"
+)
+
+var cleanDeepFlag = false
+
+func init() {
+	flag.BoolVar(&cleanDeepFlag, "clean-deep", false,
+		"look for transitively-unneeded dependencies even when there are no plain orphans")
+}
+
+// pkgDeps is the subset of "pacman -Qi" we need to compute reverse
+// dependencies: the packages and optional-dependency relationships that
+// currently keep a package installed.
+type pkgDeps struct {
+	requiredBy  []string
+	optionalFor []string
+}
+
+var qiFieldRegexp = regexp.MustCompile(`^([A-Za-z][A-Za-z ]*?)\s*: (.*)$`)
+
+// parsePacmanQi parses the output of "pacman -Qi name1 name2 ..." into a map
+// keyed by package name. "Required By" and "Optional For" wrap onto
+// indented continuation lines with no "Key :" prefix once a package has
+// enough reverse dependencies (e.g. glibc), so those are appended to
+// whichever of the two fields was most recently seen.
+func parsePacmanQi(output []byte) map[string]pkgDeps {
+	result := make(map[string]pkgDeps)
+	var name string
+	var deps pkgDeps
+	var lastField *[]string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if name != "" {
+				result[name] = deps
+			}
+			name, deps = "", pkgDeps{}
+			lastField = nil
+			continue
+		}
+		if matches := qiFieldRegexp.FindStringSubmatch(line); matches != nil {
+			lastField = nil
+			switch key, value := matches[1], matches[2]; key {
+			case "Name":
+				name = value
+			case "Required By":
+				lastField = &deps.requiredBy
+				if value != "None" {
+					*lastField = append(*lastField, strings.Fields(value)...)
+				}
+			case "Optional For":
+				lastField = &deps.optionalFor
+				if value != "None" {
+					*lastField = append(*lastField, strings.Fields(value)...)
+				}
+			}
+			continue
+		}
+		if lastField != nil && strings.HasPrefix(line, " ") {
+			*lastField = append(*lastField, strings.Fields(line)...)
+		}
+	}
+	if name != "" {
+		result[name] = deps
+	}
+	return result
+}
+
+// orphanPackages returns packages installed as a dependency with no
+// reverse dependency at all, per pacman -Qqtd.
+func orphanPackages() ([]string, error) {
+	out, err := exec.Command("sudo", "pacman", "-Qqtd").Output()
+	if err != nil {
+		if err, ok := err.(*exec.ExitError); ok && err.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("pacman -Qqtd: %w", err)
+	}
+	return splitLines(string(out)), nil
+}
+
+// dependencyPackages returns every package installed as a dependency of
+// another package.
+func dependencyPackages() ([]string, error) {
+	out, err := exec.Command("pacman", "-Qqd").Output()
+	if err != nil {
+		if err, ok := err.(*exec.ExitError); ok && err.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("pacman -Qqd: %w", err)
+	}
+	return splitLines(string(out)), nil
+}
+
+// dependencyGraph returns the reverse-dependency info for the given
+// packages, as reported by pacman -Qi.
+func dependencyGraph(names []string) (map[string]pkgDeps, error) {
+	if len(names) == 0 {
+		return map[string]pkgDeps{}, nil
+	}
+	out, err := exec.Command("pacman", append([]string{"-Qi"}, names...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("pacman -Qi: %w", err)
+	}
+	return parsePacmanQi(out), nil
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// resolveTransitivelyUnneeded computes the fixpoint of: a package is
+// unneeded if every package listed in its Required By and Optional For is
+// itself unneeded -- i.e. nothing outside the candidate set keeps it
+// installed. Starting from the full set of dependency packages and
+// pruning outwards-pointing ones to a fixpoint finds not just plain
+// orphans and chains hanging off them, but also islands of packages that
+// only require each other, which pacman -Qqtd's single-pass view misses
+// because each member individually still has a (circular) reverse
+// dependency.
+//
+// It returns just the newly-discovered packages, i.e. the result minus
+// orphans.
+func resolveTransitivelyUnneeded(graph map[string]pkgDeps, orphans []string) []string {
+	candidates := make(map[string]bool, len(graph))
+	for name := range graph {
+		candidates[name] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for name := range candidates {
+			deps := graph[name]
+			if !allWithin(deps.requiredBy, candidates) || !allWithin(deps.optionalFor, candidates) {
+				delete(candidates, name)
+				changed = true
+			}
+		}
+	}
+	var transitive []string
+	for name := range candidates {
+		if !contains(orphans, name) {
+			transitive = append(transitive, name)
+		}
+	}
+	sort.Strings(transitive)
+	return transitive
+}
+
+func allWithin(names []string, set map[string]bool) bool {
+	for _, name := range names {
+		if !set[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanDependencies offers to remove pacman's own orphans together with
+// packages that only become unneeded transitively, grouping the two in the
+// printed candidate list and letting the user pick which to actually
+// remove via the same selection syntax as the interactive update menu.
+func cleanDependencies(logger *log.Logger) error {
+	orphans, err := orphanPackages()
+	if err != nil {
+		return err
+	}
+	sort.Strings(orphans)
+
+	if len(orphans) == 0 && !cleanDeepFlag {
+		logger.Info("\nNo superfluous packages.")
+		return nil
+	}
+
+	deps, err := dependencyPackages()
+	if err != nil {
+		return err
+	}
+	graph, err := dependencyGraph(deps)
+	if err != nil {
+		return err
+	}
+	transitive := resolveTransitivelyUnneeded(graph, orphans)
+
+	if len(orphans) == 0 && len(transitive) == 0 {
+		logger.Info("\nNo superfluous packages.")
+		return nil
+	}
+
+	candidates := append(append([]string{}, orphans...), transitive...)
+
+	logger.Info("\nPackages that can be removed:")
+	n := 0
+	if len(orphans) > 0 {
+		logger.Info("  orphans:")
+		for _, name := range orphans {
+			n++
+			logger.Info("  %3d) %s", n, name)
+		}
+	}
+	if len(transitive) > 0 {
+		logger.Info("  transitively unneeded:")
+		for _, name := range transitive {
+			n++
+			logger.Info("  %3d) %s", n, name)
+		}
+	}
+
+	fmt.Print("\nSelect packages to remove (e.g. \"1-3 5 ^2\", or \"all\"): ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	indices, err := intrange.Parse(strings.TrimSpace(line), len(candidates))
+	if err != nil {
+		return err
+	}
+	if len(indices) == 0 {
+		logger.Info("Nothing selected.")
+		return nil
+	}
+
+	names := make([]string, len(indices))
+	for i, idx := range indices {
+		names[i] = candidates[idx-1]
+	}
+
+	cmd := exec.Command("sudo", append([]string{"pacman", "-Rsn"}, names...)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
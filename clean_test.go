@@ -0,0 +1,200 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func TestParsePacmanQi(t *testing.T) {
+	out := `Name            : foo
+Version         : 1.0-1
+Required By     : bar  baz
+Optional For    : None
+
+Name            : bar
+Version         : 2.0-1
+Required By     : None
+Optional For    : qux
+
+`
+	got := parsePacmanQi([]byte(out))
+	want := map[string]pkgDeps{
+		"foo": {requiredBy: []string{"bar", "baz"}},
+		"bar": {optionalFor: []string{"qux"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePacmanQi() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParsePacmanQiWrappedLines(t *testing.T) {
+	// A widely-depended package like glibc wraps "Required By" onto
+	// indented continuation lines with no "Key :" prefix.
+	out := `Name            : glibc
+Version         : 2.39-1
+Required By     : bar  baz
+                  qux
+Optional For    : quux
+                  corge
+
+`
+	got := parsePacmanQi([]byte(out))
+	want := map[string]pkgDeps{
+		"glibc": {
+			requiredBy:  []string{"bar", "baz", "qux"},
+			optionalFor: []string{"quux", "corge"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePacmanQi() = %#v, want %#v", got, want)
+	}
+}
+
+func TestResolveTransitivelyUnneeded(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   map[string]pkgDeps
+		orphans []string
+		want    []string
+	}{
+		{
+			name: "simple chain",
+			// b depends only on a (an orphan); once a is gone, b is unneeded too.
+			graph: map[string]pkgDeps{
+				"a": {},
+				"b": {requiredBy: []string{"a"}},
+				"c": {requiredBy: []string{"other"}},
+			},
+			orphans: []string{"a"},
+			want:    []string{"b"},
+		},
+		{
+			name: "multi-provides: still needed via another provider",
+			// both impl1 and impl2 satisfy the same virtual dependency of
+			// "consumer"; consumer isn't an orphan, so neither becomes
+			// unneeded even though impl1's only other reverse dep is gone.
+			graph: map[string]pkgDeps{
+				"impl1": {requiredBy: []string{"consumer"}, optionalFor: []string{"a"}},
+				"impl2": {requiredBy: []string{"consumer"}},
+				"a":     {},
+			},
+			orphans: []string{"a"},
+			want:    nil,
+		},
+		{
+			name: "mutual cycle with no external anchor is still found",
+			// x and y only require each other and nothing outside the pair
+			// needs either; pacman -Qqtd misses this because each member
+			// individually still has a (circular) reverse dependency, but
+			// pruning outwards-pointing candidates to a fixpoint catches it.
+			graph: map[string]pkgDeps{
+				"x": {requiredBy: []string{"y"}},
+				"y": {requiredBy: []string{"x"}},
+			},
+			orphans: nil,
+			want:    []string{"x", "y"},
+		},
+		{
+			name: "cycle anchored externally is kept",
+			// same mutual cycle, but z (outside the dependency set) also
+			// requires x, so neither x nor y can be pruned.
+			graph: map[string]pkgDeps{
+				"x": {requiredBy: []string{"y", "z"}},
+				"y": {requiredBy: []string{"x"}},
+			},
+			orphans: nil,
+			want:    nil,
+		},
+		{
+			name: "multi-level transitive chain",
+			graph: map[string]pkgDeps{
+				"a": {},
+				"b": {requiredBy: []string{"a"}},
+				"c": {requiredBy: []string{"b"}},
+			},
+			orphans: []string{"a"},
+			want:    []string{"b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveTransitivelyUnneeded(tt.graph, tt.orphans)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveTransitivelyUnneeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeFakePacman installs a shell script named "pacman" on PATH for the
+// duration of the test, dispatching on its first argument.
+func writeFakePacman(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("fake pacman script requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pacman")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+}
+
+func TestDependencyPackages(t *testing.T) {
+	writeFakePacman(t, `
+if [ "$1" = "-Qqd" ]; then
+  echo foo
+  echo bar
+fi
+`)
+	got, err := dependencyPackages()
+	if err != nil {
+		t.Fatalf("dependencyPackages: %v", err)
+	}
+	if want := []string{"foo", "bar"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyPackages() = %v, want %v", got, want)
+	}
+}
+
+func TestDependencyPackagesNoneInstalled(t *testing.T) {
+	writeFakePacman(t, `
+if [ "$1" = "-Qqd" ]; then
+  exit 1
+fi
+`)
+	got, err := dependencyPackages()
+	if err != nil {
+		t.Fatalf("dependencyPackages: %v", err)
+	}
+	if got != nil {
+		t.Errorf("dependencyPackages() = %v, want nil", got)
+	}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	writeFakePacman(t, `
+if [ "$1" = "-Qi" ]; then
+  shift
+  for name in "$@"; do
+    echo "Name            : $name"
+    echo "Required By     : None"
+    echo "Optional For    : None"
+    echo
+  done
+fi
+`)
+	got, err := dependencyGraph([]string{"foo", "bar"})
+	if err != nil {
+		t.Fatalf("dependencyGraph: %v", err)
+	}
+	want := map[string]pkgDeps{"foo": {}, "bar": {}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyGraph() = %#v, want %#v", got, want)
+	}
+}
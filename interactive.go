@@ -0,0 +1,109 @@
+package main
+
+// Interactive package selection for the -Syu step: instead of upgrading
+// everything unconditionally, list the pending upgrades and let the user
+// pick a subset via internal/intrange.
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/c4rlo/archupd/internal/intrange"
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiGreen   = "\x1b[32m"
+	ansiMagenta = "\x1b[35m"
+)
+
+var officialRepos = map[string]bool{
+	"core":      true,
+	"extra":     true,
+	"community": true,
+	"multilib":  true,
+}
+
+type pendingUpgrade struct {
+	repo    string
+	name    string
+	version string
+}
+
+func (u pendingUpgrade) String() string {
+	color := ansiMagenta
+	if officialRepos[u.repo] {
+		color = ansiGreen
+	}
+	return fmt.Sprintf("%s%s/%s%s %s", color, u.repo, u.name, ansiReset, u.version)
+}
+
+// listPendingUpgrades enumerates the packages pacman -Syu would upgrade,
+// without downloading or installing anything.
+func listPendingUpgrades() ([]pendingUpgrade, error) {
+	out, err := exec.Command("pacman", "-Sup", "--print-format", "%r/%n %v").Output()
+	if err != nil {
+		return nil, fmt.Errorf("pacman -Sup: %w", err)
+	}
+	var upgrades []pendingUpgrade
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		repo, name, ok := strings.Cut(fields[0], "/")
+		if !ok {
+			continue
+		}
+		upgrades = append(upgrades, pendingUpgrade{repo: repo, name: name, version: fields[1]})
+	}
+	return upgrades, scanner.Err()
+}
+
+// interactiveUpdate refreshes the sync databases, then lets the user pick
+// which of the pending upgrades to install now; the rest are left for a
+// future run.
+func interactiveUpdate() error {
+	if err := pacman("-Sy", "--noconfirm"); err != nil {
+		return err
+	}
+
+	upgrades, err := listPendingUpgrades()
+	if err != nil {
+		return err
+	}
+	if len(upgrades) == 0 {
+		fmt.Println("\nNothing to do.")
+		return nil
+	}
+
+	fmt.Println("\nPending upgrades:")
+	for i, u := range upgrades {
+		fmt.Printf("  %s%3d)%s %s\n", ansiBold, i+1, ansiReset, u)
+	}
+	fmt.Print("\nSelect packages to upgrade now (e.g. \"1-3 5 ^2\", or \"all\"): ")
+
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	indices, err := intrange.Parse(strings.TrimSpace(line), len(upgrades))
+	if err != nil {
+		return err
+	}
+	if len(indices) == 0 {
+		fmt.Println("Nothing selected; deferring all upgrades.")
+		return nil
+	}
+
+	names := make([]string, len(indices))
+	for i, idx := range indices {
+		names[i] = upgrades[idx-1].name
+	}
+	if deferred := len(upgrades) - len(indices); deferred > 0 {
+		fmt.Printf("Deferring %d upgrade(s).\n", deferred)
+	}
+	return pacman(append([]string{"-S", "--noconfirm"}, names...)...)
+}
@@ -0,0 +1,252 @@
+// Package aur provides minimal support for checking foreign (AUR) packages
+// for available upgrades, without pulling in a full build pipeline.
+package aur
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const rpcURL = "https://aur.archlinux.org/rpc/?v=5&type=info"
+const packagesURL = "https://aur.archlinux.org/packages.gz"
+const pkgbuildURLFmt = "https://aur.archlinux.org/cgit/aur.git/plain/PKGBUILD?h=%s"
+const packageListTTL = 48 * time.Hour
+
+// httpClient is shared by every AUR request. showAurUpdates runs
+// synchronously as part of the main update flow unless --no-aur is given, so a
+// bounded timeout keeps a stalled AUR endpoint from hanging the whole run,
+// the same reasoning archupd.go applies to its own news feed client.
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Package is the subset of AUR RPC package info we care about.
+type Package struct {
+	Name    string
+	Version string
+}
+
+// Upgrade describes a foreign package with a newer version available in the AUR.
+type Upgrade struct {
+	Name      string
+	Installed string
+	Latest    string
+}
+
+type rpcResponse struct {
+	Results []struct {
+		Name    string `json:"Name"`
+		Version string `json:"Version"`
+	} `json:"results"`
+}
+
+// installedForeign returns the name/version of every foreign package
+// (i.e. not found in a sync repo), as reported by "pacman -Qm".
+func installedForeign() (map[string]string, error) {
+	out, err := exec.Command("pacman", "-Qm").Output()
+	if err != nil {
+		return nil, fmt.Errorf("pacman -Qm: %w", err)
+	}
+	pkgs := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		pkgs[fields[0]] = fields[1]
+	}
+	return pkgs, nil
+}
+
+// cacheFileName returns the path of the cached AUR package name list,
+// stored next to archupd's own state file.
+func cacheFileName() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		homePath, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateHome = filepath.Join(homePath, ".local", "state")
+	}
+	return filepath.Join(stateHome, "archupd-aur-packages.txt"), nil
+}
+
+// knownPackageNames returns the set of all AUR package names, refreshing
+// the on-disk cache from packages.gz if it is missing or older than
+// packageListTTL.
+func knownPackageNames() (map[string]struct{}, error) {
+	fileName, err := cacheFileName()
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(fileName); err == nil && time.Since(info.ModTime()) < packageListTTL {
+		return readPackageList(fileName)
+	}
+
+	if err := refreshPackageList(fileName); err != nil {
+		// Fall back to a stale cache rather than failing outright.
+		if _, statErr := os.Stat(fileName); statErr == nil {
+			return readPackageList(fileName)
+		}
+		return nil, err
+	}
+	return readPackageList(fileName)
+}
+
+func refreshPackageList(fileName string) error {
+	resp, err := httpClient.Get(packagesURL)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", packagesURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: unexpected HTTP status: %s", packagesURL, resp.Status)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.ReadFrom(gz); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
+
+func readPackageList(fileName string) (map[string]struct{}, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names[line] = struct{}{}
+	}
+	return names, scanner.Err()
+}
+
+// fetchInfo queries the AUR RPC info endpoint for the given package names.
+func fetchInfo(names []string) (map[string]Package, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	url := rpcURL
+	for _, name := range names {
+		url += "&arg[]=" + name
+	}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("querying AUR RPC: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("querying AUR RPC: unexpected HTTP status: %s", resp.Status)
+	}
+
+	var parsed rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decoding AUR RPC response: %w", err)
+	}
+
+	result := make(map[string]Package, len(parsed.Results))
+	for _, r := range parsed.Results {
+		result[r.Name] = Package{Name: r.Name, Version: r.Version}
+	}
+	return result, nil
+}
+
+// FetchPKGBUILD downloads the current PKGBUILD for an AUR package, as
+// published in the AUR's package git repo.
+func FetchPKGBUILD(name string) (string, error) {
+	url := fmt.Sprintf(pkgbuildURLFmt, name)
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("fetching PKGBUILD for %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching PKGBUILD for %s: unexpected HTTP status: %s", name, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("fetching PKGBUILD for %s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// CheckUpdates reports the installed foreign packages for which the AUR
+// has a newer version than what is installed.
+func CheckUpdates() ([]Upgrade, error) {
+	foreign, err := installedForeign()
+	if err != nil {
+		return nil, err
+	}
+	if len(foreign) == 0 {
+		return nil, nil
+	}
+
+	known, err := knownPackageNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range foreign {
+		if _, ok := known[name]; ok {
+			names = append(names, name)
+		}
+	}
+
+	infos, err := fetchInfo(names)
+	if err != nil {
+		return nil, err
+	}
+
+	var upgrades []Upgrade
+	for name, installed := range foreign {
+		info, ok := infos[name]
+		if !ok {
+			continue
+		}
+		if vercmp(info.Version, installed) > 0 {
+			upgrades = append(upgrades, Upgrade{
+				Name:      name,
+				Installed: installed,
+				Latest:    info.Version,
+			})
+		}
+	}
+	return upgrades, nil
+}
@@ -0,0 +1,139 @@
+package aur
+
+import (
+	"strconv"
+	"strings"
+)
+
+// vercmp compares two package versions ([epoch:]pkgver[-pkgrel]) using the
+// same rules as alpm's vercmp / libalpm's alpm_pkg_vercmp: epoch is compared
+// numerically first, then pkgver and pkgrel are compared segment by
+// segment, alternating between numeric and alphabetic runs. It returns a
+// negative number if a < b, zero if equal, and a positive number if a > b.
+func vercmp(a, b string) int {
+	aEpoch, aRest := splitEpoch(a)
+	bEpoch, bRest := splitEpoch(b)
+	if c := compareInts(aEpoch, bEpoch); c != 0 {
+		return c
+	}
+	aVer, aRel, aHasRel := strings.Cut(aRest, "-")
+	bVer, bRel, bHasRel := strings.Cut(bRest, "-")
+	if c := compareSegments(aVer, bVer); c != 0 || !aHasRel || !bHasRel {
+		return c
+	}
+	return compareSegments(aRel, bRel)
+}
+
+func splitEpoch(v string) (int, string) {
+	if i := strings.IndexByte(v, ':'); i >= 0 {
+		epoch, err := strconv.Atoi(v[:i])
+		if err == nil {
+			return epoch, v[i+1:]
+		}
+	}
+	return 0, v
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareSegments compares two pkgver (or two pkgrel) strings segment by
+// segment, splitting on runs of alphanumeric characters the same way
+// libalpm does.
+func compareSegments(a, b string) int {
+	aSegs := splitSegments(a)
+	bSegs := splitSegments(b)
+
+	for i := 0; i < len(aSegs) || i < len(bSegs); i++ {
+		if i >= len(aSegs) {
+			// a ran out of segments: it's older, unless the remaining
+			// segment on b is purely alphabetic (alpm treats a trailing
+			// alpha segment as a pre-release, i.e. "older").
+			if isAlpha(bSegs[i]) {
+				return 1
+			}
+			return -1
+		}
+		if i >= len(bSegs) {
+			if isAlpha(aSegs[i]) {
+				return -1
+			}
+			return 1
+		}
+		if c := compareSegment(aSegs[i], bSegs[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareSegment(a, b string) int {
+	aNum, aIsNum := parseNum(a)
+	bNum, bIsNum := parseNum(b)
+	switch {
+	case aIsNum && bIsNum:
+		return compareInts(aNum, bNum)
+	case aIsNum && !bIsNum:
+		return 1
+	case !aIsNum && bIsNum:
+		return -1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func parseNum(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if r < 'a' || r > 'z' {
+			if r < 'A' || r > 'Z' {
+				return false
+			}
+		}
+	}
+	return s != ""
+}
+
+func isAlnum(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// splitSegments splits a version string into runs of alphanumeric
+// characters, discarding any other separator characters ('.', '-', '+', ...).
+func splitSegments(v string) []string {
+	var segs []string
+	var curr strings.Builder
+	currIsDigit := false
+	for _, r := range v {
+		if !isAlnum(r) {
+			if curr.Len() > 0 {
+				segs = append(segs, curr.String())
+				curr.Reset()
+			}
+			continue
+		}
+		isDigit := r >= '0' && r <= '9'
+		if curr.Len() > 0 && isDigit != currIsDigit {
+			segs = append(segs, curr.String())
+			curr.Reset()
+		}
+		curr.WriteRune(r)
+		currIsDigit = isDigit
+	}
+	if curr.Len() > 0 {
+		segs = append(segs, curr.String())
+	}
+	return segs
+}
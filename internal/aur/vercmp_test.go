@@ -0,0 +1,43 @@
+package aur
+
+import "testing"
+
+func TestVercmp(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{"equal", "1.0-1", "1.0-1", 0},
+		{"newer pkgver", "1.1-1", "1.0-1", 1},
+		{"older pkgver", "1.0-1", "1.1-1", -1},
+		{"newer pkgrel", "1.0-2", "1.0-1", 1},
+		{"higher epoch wins regardless of pkgver", "1:1.0-1", "2.0-1", 1},
+		{"lower epoch loses regardless of pkgver", "1.0-1", "1:0.1-1", -1},
+		{"missing epoch treated as zero", "1.0-1", "0:1.0-1", 0},
+		{"mixed alpha/numeric segments", "1.0.a-1", "1.0.b-1", -1},
+		{"numeric segment outranks alpha segment", "1.0.2-1", "1.0.a-1", 1},
+		{"trailing alpha segment is a pre-release", "1.0a", "1.0", -1},
+		{"version with trailing numeric segment is newer than pre-release", "1.0", "1.0a", 1},
+		{"longer numeric segment wins", "1.10-1", "1.9-1", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vercmp(tt.a, tt.b); sign(got) != sign(tt.want) {
+				t.Errorf("vercmp(%q, %q) = %d, want sign %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,96 @@
+// Package intrange parses human-typed selection expressions over a range
+// of 1-based indices, such as "1-3 5 ^2" or "all", into a sorted list of
+// selected indices.
+package intrange
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses expr, a whitespace- or comma-separated list of selectors
+// over the range [1, max], and returns the sorted list of selected
+// indices. Recognised selectors are:
+//
+//	all    selects every index in [1, max]
+//	N      selects index N
+//	N-M    selects every index in [N, M] (inclusive; N > M is allowed)
+//	^N     excludes index N
+//	^N-M   excludes every index in [N, M]
+//
+// Exclusions are applied after all inclusions regardless of where they
+// appear in expr, so "all ^3" and "^3 all" are equivalent. Excluding
+// indices outside [1, max] is not an error; including them is.
+func Parse(expr string, max int) ([]int, error) {
+	fields := strings.FieldsFunc(expr, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	})
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty selection")
+	}
+
+	selected := make(map[int]bool)
+	excluded := make(map[int]bool)
+
+	for _, field := range fields {
+		exclude := strings.HasPrefix(field, "^")
+		if exclude {
+			field = field[1:]
+		}
+
+		if field == "all" {
+			if exclude {
+				return nil, fmt.Errorf("%q is not a valid selector", "^all")
+			}
+			for i := 1; i <= max; i++ {
+				selected[i] = true
+			}
+			continue
+		}
+
+		lo, hi, err := parseRange(field)
+		if err != nil {
+			return nil, err
+		}
+		if !exclude && (lo < 1 || hi > max) {
+			return nil, fmt.Errorf("selector %q is out of range [1, %d]", field, max)
+		}
+		for i := lo; i <= hi; i++ {
+			if exclude {
+				excluded[i] = true
+			} else {
+				selected[i] = true
+			}
+		}
+	}
+
+	var result []int
+	for i := 1; i <= max; i++ {
+		if selected[i] && !excluded[i] {
+			result = append(result, i)
+		}
+	}
+	return result, nil
+}
+
+// parseRange parses a single "N" or "N-M" selector, swapping the bounds if
+// they are given in descending order.
+func parseRange(field string) (int, int, error) {
+	before, after, isRange := strings.Cut(field, "-")
+	lo, err := strconv.Atoi(before)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid selector %q", field)
+	}
+	if !isRange {
+		return lo, lo, nil
+	}
+	hi, err := strconv.Atoi(after)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid selector %q", field)
+	}
+	if hi < lo {
+		lo, hi = hi, lo
+	}
+	return lo, hi, nil
+}
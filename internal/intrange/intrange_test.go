@@ -0,0 +1,58 @@
+package intrange
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		max  int
+		want []int
+	}{
+		{"single", "3", 5, []int{3}},
+		{"range", "2-4", 5, []int{2, 3, 4}},
+		{"inverted range", "4-2", 5, []int{2, 3, 4}},
+		{"overlap", "1-3 2-5", 5, []int{1, 2, 3, 4, 5}},
+		{"all", "all", 5, []int{1, 2, 3, 4, 5}},
+		{"exclusion", "all ^3", 5, []int{1, 2, 4, 5}},
+		{"exclusion range", "all ^2-4", 5, []int{1, 5}},
+		{"exclusion order independent", "^3 all", 5, []int{1, 2, 4, 5}},
+		{"exclusion larger than set", "all ^7-9", 5, []int{1, 2, 3, 4, 5}},
+		{"comma separated", "1,3,5", 5, []int{1, 3, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.expr, tt.max)
+			if err != nil {
+				t.Fatalf("Parse(%q, %d) returned error: %v", tt.expr, tt.max, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q, %d) = %v, want %v", tt.expr, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		max  int
+	}{
+		{"empty", "", 5},
+		{"not a number", "foo", 5},
+		{"out of range", "6", 5},
+		{"out of range low", "0", 5},
+		{"exclude all", "^all", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.expr, tt.max); err == nil {
+				t.Errorf("Parse(%q, %d) expected an error, got none", tt.expr, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Package log provides the small leveled, topic-gated logger used to route
+// archupd's own diagnostic output, as opposed to output from pacman itself.
+//
+// Info writes to stdout; Warn and Error write to stderr, so that piping
+// archupd's stdout doesn't also capture its own diagnostics. Debug traces
+// are off by default and gated per topic by the ARCHUPD_DEBUG environment
+// variable, a comma-separated list of topics to enable (e.g.
+// "news,alpm,changelog", or "all" for everything).
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var debugTopics = parseDebugTopics(os.Getenv("ARCHUPD_DEBUG"))
+
+func parseDebugTopics(v string) map[string]bool {
+	topics := make(map[string]bool)
+	for _, t := range strings.Split(v, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			topics[t] = true
+		}
+	}
+	return topics
+}
+
+// Logger writes leveled output for a single topic (e.g. "news", "alpm",
+// "changelog"). Construct one with New and thread it through the code that
+// needs to log, rather than reaching for package-level state.
+type Logger struct {
+	topic string
+	debug bool
+	out   io.Writer
+	err   io.Writer
+}
+
+// New returns a Logger for topic. Its Debug traces are enabled if topic (or
+// "all") appears in ARCHUPD_DEBUG.
+func New(topic string) *Logger {
+	return &Logger{
+		topic: topic,
+		debug: debugTopics[topic] || debugTopics["all"],
+		out:   os.Stdout,
+		err:   os.Stderr,
+	}
+}
+
+// Info writes an informational message to stdout.
+func (l *Logger) Info(format string, args ...any) {
+	fmt.Fprintf(l.out, format+"\n", args...)
+}
+
+// Warn writes a warning to stderr.
+func (l *Logger) Warn(format string, args ...any) {
+	fmt.Fprintf(l.err, "warning: "+format+"\n", args...)
+}
+
+// Error writes an error to stderr.
+func (l *Logger) Error(format string, args ...any) {
+	fmt.Fprintf(l.err, "error: "+format+"\n", args...)
+}
+
+// Debug writes a trace to stderr, prefixed with the logger's topic, if that
+// topic was enabled via ARCHUPD_DEBUG.
+func (l *Logger) Debug(format string, args ...any) {
+	if !l.debug {
+		return
+	}
+	fmt.Fprintf(l.err, "["+l.topic+"] "+format+"\n", args...)
+}
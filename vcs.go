@@ -0,0 +1,245 @@
+package main
+
+// Detection of installed VCS/development packages (-git, -svn, -hg) whose
+// upstream source has advanced since the package was last built, so that
+// they can be offered for a rebuild alongside the regular update.
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/c4rlo/archupd/internal/aur"
+	"github.com/c4rlo/archupd/internal/log"
+)
+
+var develPkgSuffixRegexp = regexp.MustCompile(`-(git|svn|hg)$`)
+var vcsSourceRegexp = regexp.MustCompile(`(git|svn|hg)\+([^\s"')]+)`)
+
+type vcsSource struct {
+	kind string // "git", "svn", or "hg"
+	url  string
+}
+
+// develPackages returns the names of installed foreign packages (as listed
+// by pacman -Qmq) whose name ends in -git, -svn or -hg.
+func develPackages() ([]string, error) {
+	out, err := exec.Command("pacman", "-Qmq").Output()
+	if err != nil {
+		return nil, fmt.Errorf("pacman -Qmq: %w", err)
+	}
+	var names []string
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		if name := scanner.Text(); develPkgSuffixRegexp.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// pkgbuildPath returns where the PKGBUILD last fetched from the AUR for pkg
+// is cached (written via cachePKGBUILD).
+func pkgbuildPath(pkg string) string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		if homePath, err := os.UserHomeDir(); err == nil {
+			cacheHome = filepath.Join(homePath, ".cache")
+		}
+	}
+	return filepath.Join(cacheHome, "archupd", "pkgbuild", pkg, "PKGBUILD")
+}
+
+// cachePKGBUILD writes contents to path atomically, via a temp file and
+// rename in the same directory, creating that directory if necessary.
+func cachePKGBUILD(path, contents string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// parseVCSSources extracts VCS source URLs (e.g. "git+https://...#branch=x")
+// from a cached PKGBUILD.
+func parseVCSSources(path string) ([]vcsSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sources []vcsSource
+	for _, m := range vcsSourceRegexp.FindAllStringSubmatch(string(data), -1) {
+		sources = append(sources, vcsSource{kind: m[1], url: m[2]})
+	}
+	return sources, nil
+}
+
+// latestRevision queries upstream for the current commit/revision of src.
+func latestRevision(src vcsSource) (string, error) {
+	switch src.kind {
+	case "git":
+		url, fragment, _ := strings.Cut(src.url, "#")
+		ref := "HEAD"
+		if _, branch, ok := strings.Cut(fragment, "="); ok && branch != "" {
+			ref = branch
+		}
+		out, err := exec.Command("git", "ls-remote", url, ref).Output()
+		if err != nil {
+			return "", fmt.Errorf("git ls-remote %s: %w", url, err)
+		}
+		fields := strings.Fields(string(out))
+		if len(fields) == 0 {
+			return "", fmt.Errorf("git ls-remote %s: no matching ref %q", url, ref)
+		}
+		return fields[0], nil
+	case "svn":
+		out, err := exec.Command("svn", "info", "--show-item", "last-changed-revision", src.url).Output()
+		if err != nil {
+			return "", fmt.Errorf("svn info %s: %w", src.url, err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	case "hg":
+		out, err := exec.Command("hg", "identify", src.url).Output()
+		if err != nil {
+			return "", fmt.Errorf("hg identify %s: %w", src.url, err)
+		}
+		fields := strings.Fields(string(out))
+		if len(fields) == 0 {
+			return "", fmt.Errorf("hg identify %s: no revision reported", src.url)
+		}
+		return fields[0], nil
+	default:
+		return "", fmt.Errorf("unsupported VCS kind %q", src.kind)
+	}
+}
+
+// checkVCSUpdates scans installed devel packages for upstream changes,
+// returning the names of those whose upstream has moved since last seen.
+// state.VCSState is updated in place with the revisions found; a package
+// with multiple VCS sources is flagged if any one of them has advanced.
+// Network failures while checking an individual source are not fatal: they
+// are reported but do not abort the overall scan.
+func checkVCSUpdates(logger *log.Logger, state *State) ([]string, error) {
+	return checkVCSUpdatesWith(logger, state, develPackages, aur.FetchPKGBUILD)
+}
+
+// checkVCSUpdatesWith is checkVCSUpdates with develPackages and
+// aur.FetchPKGBUILD injected, so tests can fake both the list of installed
+// devel packages and the AUR's responses.
+func checkVCSUpdatesWith(
+	logger *log.Logger,
+	state *State,
+	develPackages func() ([]string, error),
+	fetchPKGBUILD func(string) (string, error),
+) ([]string, error) {
+	pkgs, err := develPackages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, nil
+	}
+	if state.VCSState == nil {
+		state.VCSState = make(VCSState)
+	}
+
+	var needRebuild []string
+	for _, pkg := range pkgs {
+		// The cache at pkgbuildPath is otherwise only populated as a
+		// side effect of showAurPKGBUILDDiffs, which only fires when
+		// aur.CheckUpdates sees a version bump -- something a devel
+		// package's static AUR-listed pkgver essentially never
+		// triggers. Refresh it here too, so parseVCSSources below has
+		// a PKGBUILD to read on a fresh machine as well as a stale one.
+		data, err := fetchPKGBUILD(pkg)
+		if err != nil {
+			logger.Error("%s: %v", pkg, err)
+			continue
+		}
+		if err := cachePKGBUILD(pkgbuildPath(pkg), data); err != nil {
+			logger.Error("%s: %v", pkg, err)
+			continue
+		}
+
+		sources, err := parseVCSSources(pkgbuildPath(pkg))
+		if err != nil {
+			logger.Error("%s: %v", pkg, err)
+			continue
+		}
+		prevRevs := state.VCSState[pkg]
+		newRevs := make(map[string]string, len(sources))
+		advanced := false
+		for _, src := range sources {
+			rev, err := latestRevision(src)
+			if err != nil {
+				logger.Error("%s: %v", pkg, err)
+				if prevRevs != nil {
+					newRevs[src.url] = prevRevs[src.url]
+				}
+				continue
+			}
+			newRevs[src.url] = rev
+			if prevRevs == nil || prevRevs[src.url] != rev {
+				advanced = true
+			}
+		}
+		state.VCSState[pkg] = newRevs
+		if advanced {
+			needRebuild = append(needRebuild, pkg)
+		}
+	}
+	return needRebuild, nil
+}
+
+// rebuildVCSPackage rebuilds pkg from its cached PKGBUILD via makepkg and
+// installs the result. -git/-svn/-hg packages are foreign AUR packages with
+// no sync-repo target, so "pacman -S" cannot install them; makepkg is what
+// actually builds the new package and, with -i, installs it afterwards.
+func rebuildVCSPackage(pkg string) error {
+	cmd := exec.Command("makepkg", "-si", "--noconfirm")
+	cmd.Dir = filepath.Dir(pkgbuildPath(pkg))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// offerVCSRebuild lists devel packages whose upstream has moved and, if the
+// user confirms, rebuilds and installs each of them via makepkg. A failure
+// rebuilding one package is reported but does not stop the rest from being
+// attempted.
+func offerVCSRebuild(logger *log.Logger, pkgs []string) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	logger.Info("\nDevelopment packages with upstream changes:")
+	for _, pkg := range pkgs {
+		logger.Info("  - %s", pkg)
+	}
+	fmt.Print("Rebuild these now? [y/N] ")
+	resp, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.ToLower(strings.TrimSpace(resp)) != "y" {
+		return nil
+	}
+	for _, pkg := range pkgs {
+		if err := rebuildVCSPackage(pkg); err != nil {
+			logger.Error("rebuild %s: %v", pkg, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/c4rlo/archupd/internal/log"
+)
+
+var errFetchFailed = errors.New("simulated AUR fetch failure")
+
+// writeFakeBinary installs a shell script under name on PATH for the
+// duration of the test.
+func writeFakeBinary(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("fake binary requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+}
+
+func TestCheckVCSUpdatesWith(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	writeFakeBinary(t, "git", `
+if [ "$1" = "ls-remote" ]; then
+  echo "abc123	HEAD"
+fi
+`)
+	writeFakeBinary(t, "svn", `
+if [ "$1" = "info" ]; then
+  echo "42"
+fi
+`)
+
+	develPackages := func() ([]string, error) { return []string{"foo-git"}, nil }
+	fetchPKGBUILD := func(pkg string) (string, error) {
+		return "source=('git+https://example.com/foo.git' 'svn+https://example.com/bar')\n", nil
+	}
+
+	state := &State{}
+	needRebuild, err := checkVCSUpdatesWith(log.New("vcs"), state, develPackages, fetchPKGBUILD)
+	if err != nil {
+		t.Fatalf("checkVCSUpdatesWith: %v", err)
+	}
+	if want := []string{"foo-git"}; !reflect.DeepEqual(needRebuild, want) {
+		t.Errorf("needRebuild = %v, want %v", needRebuild, want)
+	}
+	if got := len(state.VCSState["foo-git"]); got != 2 {
+		t.Errorf("VCSState[foo-git] has %d sources, want 2", got)
+	}
+
+	data, err := os.ReadFile(pkgbuildPath("foo-git"))
+	if err != nil {
+		t.Fatalf("PKGBUILD was not cached: %v", err)
+	}
+	if string(data) != "source=('git+https://example.com/foo.git' 'svn+https://example.com/bar')\n" {
+		t.Errorf("cached PKGBUILD = %q", data)
+	}
+
+	// Running again with the same upstream revisions reports nothing new.
+	needRebuild, err = checkVCSUpdatesWith(log.New("vcs"), state, develPackages, fetchPKGBUILD)
+	if err != nil {
+		t.Fatalf("checkVCSUpdatesWith (second run): %v", err)
+	}
+	if needRebuild != nil {
+		t.Errorf("needRebuild (second run) = %v, want nil", needRebuild)
+	}
+}
+
+func TestCheckVCSUpdatesWithFetchFailure(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	develPackages := func() ([]string, error) { return []string{"foo-git", "bar-git"}, nil }
+	fetchPKGBUILD := func(pkg string) (string, error) {
+		if pkg == "foo-git" {
+			return "", errFetchFailed
+		}
+		return "source=('git+https://example.com/bar.git')\n", nil
+	}
+	writeFakeBinary(t, "git", `
+if [ "$1" = "ls-remote" ]; then
+  echo "abc123	HEAD"
+fi
+`)
+
+	state := &State{}
+	needRebuild, err := checkVCSUpdatesWith(log.New("vcs"), state, develPackages, fetchPKGBUILD)
+	if err != nil {
+		t.Fatalf("checkVCSUpdatesWith: %v", err)
+	}
+	// foo-git's fetch failure is reported but does not abort the scan:
+	// bar-git is still checked and flagged.
+	if want := []string{"bar-git"}; !reflect.DeepEqual(needRebuild, want) {
+		t.Errorf("needRebuild = %v, want %v", needRebuild, want)
+	}
+	if _, ok := state.VCSState["foo-git"]; ok {
+		t.Errorf("VCSState[foo-git] should be absent after a fetch failure")
+	}
+}